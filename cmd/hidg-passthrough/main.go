@@ -0,0 +1,126 @@
+// Command hidg-passthrough forwards raw evdev input devices (keyboard,
+// mouse, ...) straight into the HID gadget, without going through a
+// terminal UI. This preserves scancodes and press/release timing that
+// tcell normalizes away, at the cost of needing direct access to
+// /dev/input/eventN.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/grimpy/hidg"
+)
+
+func listDevices() error {
+	f, err := os.Open("/proc/bus/input/devices")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	var name, handlers string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "N: Name="):
+			name = strings.Trim(strings.TrimPrefix(line, "N: Name="), "\"")
+		case strings.HasPrefix(line, "H: Handlers="):
+			handlers = strings.TrimPrefix(line, "H: Handlers=")
+		case line == "":
+			for _, h := range strings.Fields(handlers) {
+				if strings.HasPrefix(h, "event") {
+					fmt.Printf("/dev/input/%s\t%s\n", h, name)
+				}
+			}
+			name, handlers = "", ""
+		}
+	}
+	return scanner.Err()
+}
+
+// stringSlice collects a repeatable flag's values, e.g. "--mouse
+// /dev/input/event5 --mouse /dev/input/event7".
+type stringSlice []string
+
+func (s *stringSlice) String() string     { return strings.Join(*s, ",") }
+func (s *stringSlice) Set(v string) error { *s = append(*s, v); return nil }
+
+func main() {
+	gadget := flag.String("gadget", "/dev/hidg0", "HID keyboard gadget endpoint to write to")
+	mouseGadget := flag.String("mouse-gadget", "", "HID mouse gadget endpoint to write to, e.g. /dev/hidg1 (required if --mouse is given)")
+	list := flag.Bool("list", false, "list available /dev/input/eventN devices and exit")
+	var mice stringSlice
+	flag.Var(&mice, "mouse", "an /dev/input/eventN device to grab and forward to --mouse-gadget instead of --gadget; repeatable")
+	flag.Parse()
+
+	if *list {
+		if err := listDevices(); err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: could not list input devices:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	devices := flag.Args()
+	if len(devices) == 0 && len(mice) == 0 {
+		fmt.Fprintln(os.Stderr, "usage: hidg-passthrough [--gadget /dev/hidg0] [--mouse-gadget /dev/hidg1 --mouse /dev/input/eventM ...] /dev/input/eventN [...]")
+		os.Exit(1)
+	}
+	if len(mice) > 0 && *mouseGadget == "" {
+		fmt.Fprintln(os.Stderr, "ERROR: --mouse requires --mouse-gadget")
+		os.Exit(1)
+	}
+
+	var readers []*hidg.PassthroughReader
+
+	if len(devices) > 0 {
+		hid, err := hidg.Open(*gadget, nil)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: could not open", *gadget, err)
+			os.Exit(1)
+		}
+		defer hid.Close()
+
+		for _, path := range devices {
+			pr, err := hidg.PassthroughDevice(path, hid)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: could not grab", path, err)
+				os.Exit(1)
+			}
+			readers = append(readers, pr)
+		}
+	}
+
+	if len(mice) > 0 {
+		mouse, err := hidg.OpenMouse(*mouseGadget, 0)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "ERROR: could not open", *mouseGadget, err)
+			os.Exit(1)
+		}
+		defer mouse.Close()
+
+		for _, path := range mice {
+			pr, err := hidg.PassthroughMouseDevice(path, mouse)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "ERROR: could not grab", path, err)
+				os.Exit(1)
+			}
+			readers = append(readers, pr)
+		}
+	}
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	<-sig
+
+	for _, pr := range readers {
+		pr.Close()
+	}
+}
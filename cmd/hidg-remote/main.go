@@ -0,0 +1,133 @@
+// Command hidg-remote is the client side of pkg/hidnet: it captures
+// input locally and forwards it to a hidg server running on a different
+// host's HID gadget. With --device it reads a raw /dev/input/eventN
+// device (preserving scancodes, like cmd/hidg-passthrough, but without
+// grabbing it, so the local system still sees its events); without it,
+// it falls back to a tcell-based keyboard capture like cmd/hidg's UI.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/gdamore/tcell"
+
+	"github.com/grimpy/hidg/internal/keymaps"
+	"github.com/grimpy/hidg/internal/tcellkeys"
+	"github.com/grimpy/hidg/pkg/hidnet"
+	"github.com/maugsburger/evdev"
+)
+
+func runDevice(path string, send func(evdev.Event) error) error {
+	device, err := evdev.Open(path)
+	if err != nil {
+		return err
+	}
+	defer device.Close()
+
+	for ev := range device.Inbox {
+		if err := send(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func runTcell(keymapName string, send func(evdev.Event) error) error {
+	km, err := keymaps.Load(keymapName)
+	if err != nil {
+		return fmt.Errorf("loading keymap %q: %w", keymapName, err)
+	}
+
+	s, err := tcell.NewScreen()
+	if err != nil {
+		return err
+	}
+	if err := s.Init(); err != nil {
+		return err
+	}
+	defer s.Fini()
+
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+
+	events := make(chan tcell.Event, 1)
+	go func() {
+		for {
+			events <- s.PollEvent()
+		}
+	}()
+
+	for {
+		select {
+		case <-sig:
+			return nil
+		case ev := <-events:
+			key, ok := ev.(*tcell.EventKey)
+			if !ok {
+				continue
+			}
+			if key.Key() == tcell.KeyEscape {
+				return nil
+			}
+
+			code, mods, ok := tcellkeys.Translate(key, km)
+			if !ok {
+				continue
+			}
+			for _, m := range tcellkeys.ModKeys {
+				if mods&m.Bit != 0 {
+					send(evdev.Event{Type: evdev.EvKeys, Code: m.Code, Value: 1})
+				}
+			}
+			send(evdev.Event{Type: evdev.EvKeys, Code: code, Value: 1})
+			send(evdev.Event{Type: evdev.EvKeys, Code: code, Value: 0})
+			for _, m := range tcellkeys.ModKeys {
+				if mods&m.Bit != 0 {
+					send(evdev.Event{Type: evdev.EvKeys, Code: m.Code, Value: 0})
+				}
+			}
+		}
+	}
+}
+
+func main() {
+	server := flag.String("server", "", "remote hidg-server address, host:port")
+	cert := flag.String("cert", "", "client TLS certificate")
+	key := flag.String("key", "", "client TLS private key")
+	ca := flag.String("ca", "", "CA certificate used to verify the server")
+	device := flag.String("device", "", "raw /dev/input/eventN device to forward (omit to fall back to a tcell keyboard capture)")
+	keymapName := flag.String("keymap", "us", "keymap to use when falling back to tcell capture")
+	flag.Parse()
+
+	if *server == "" {
+		fmt.Fprintln(os.Stderr, "usage: hidg-remote --server host:port --cert ... --key ... --ca ... [--device /dev/input/eventN]")
+		os.Exit(1)
+	}
+
+	tlsConf, err := hidnet.LoadClientTLSConfig(*cert, *key, *ca)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: could not load TLS config:", err)
+		os.Exit(1)
+	}
+
+	send, closer, err := hidnet.DialAndSend(*server, tlsConf)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: could not connect to", *server, ":", err)
+		os.Exit(1)
+	}
+	defer closer.Close()
+
+	if *device != "" {
+		err = runDevice(*device, send)
+	} else {
+		err = runTcell(*keymapName, send)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
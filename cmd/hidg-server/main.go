@@ -0,0 +1,57 @@
+// Command hidg-server is the server side of pkg/hidnet: it exposes a
+// local HID gadget to hidg-remote clients over mutually authenticated
+// TLS, as either a raw binary TCP stream or a JSON-over-WebSocket
+// endpoint.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/grimpy/hidg"
+	"github.com/grimpy/hidg/pkg/hidnet"
+)
+
+func main() {
+	gadget := flag.String("gadget", "/dev/hidg0", "HID keyboard gadget endpoint to write to")
+	bind := flag.String("bind", ":4222", "address to listen on")
+	cert := flag.String("cert", "", "server TLS certificate")
+	key := flag.String("key", "", "server TLS private key")
+	ca := flag.String("ca", "", "CA certificate used to verify clients")
+	rateLimit := flag.Float64("rate", 200, "maximum events per second accepted per connection")
+	burst := flag.Int("burst", 50, "burst size for the per-connection rate limiter")
+	ws := flag.Bool("ws", false, "serve the JSON/WebSocket transport instead of the binary TCP protocol")
+	flag.Parse()
+
+	hid, err := hidg.Open(*gadget, nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: could not open", *gadget, err)
+		os.Exit(1)
+	}
+	defer hid.Close()
+
+	tlsConf, err := hidnet.LoadServerTLSConfig(*cert, *key, *ca)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR: could not load TLS config:", err)
+		os.Exit(1)
+	}
+
+	server := hidnet.NewServer(hid, tlsConf, *rateLimit, *burst)
+
+	if *ws {
+		httpServer := &http.Server{
+			Addr:      *bind,
+			Handler:   server.WebSocketHandler(),
+			TLSConfig: tlsConf,
+		}
+		err = httpServer.ListenAndServeTLS(*cert, *key)
+	} else {
+		err = server.ListenAndServe(*bind)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "ERROR:", err)
+		os.Exit(1)
+	}
+}
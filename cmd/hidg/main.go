@@ -0,0 +1,459 @@
+// Command hidg reads keyboard and mouse events and forwards them as USB
+// HID reports to /dev/hidg0 (keyboard), /dev/hidg1 (mouse) and
+// /dev/hidg2 (consumer control). On a terminal that answers a Kitty
+// keyboard protocol probe it reads raw stdin directly via seqparser, so
+// held keys get real HID key-up reports; otherwise it falls back to a
+// tcell terminal UI. Press Escape twice to exit either way.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/gdamore/tcell"
+	"github.com/gdamore/tcell/encoding"
+
+	"github.com/grimpy/hidg"
+	"github.com/grimpy/hidg/internal/keymaps"
+	"github.com/grimpy/hidg/internal/seqparser"
+	"github.com/grimpy/hidg/internal/tcellkeys"
+	"github.com/mattn/go-runewidth"
+	"github.com/maugsburger/evdev"
+	"golang.org/x/term"
+)
+
+// seqSpecialKeys maps the non-printable seqparser key codes to evdev
+// keycodes; these are the same on every layout, like tcellkeys.NonPrintable.
+var seqSpecialKeys = map[rune]uint16{
+	seqparser.KeyUp:        evdev.KeyUp,
+	seqparser.KeyDown:      evdev.KeyDown,
+	seqparser.KeyLeft:      evdev.KeyLeft,
+	seqparser.KeyRight:     evdev.KeyRight,
+	seqparser.KeyEnter:     evdev.KeyEnter,
+	seqparser.KeyTab:       evdev.KeyTab,
+	seqparser.KeyBackspace: evdev.KeyBackSpace,
+	seqparser.KeyHome:      102, // KEY_HOME
+	seqparser.KeyEnd:       107, // KEY_END
+	seqparser.KeyF1:        59,  // KEY_F1
+	seqparser.KeyF2:        60,  // KEY_F2
+	seqparser.KeyF3:        61,  // KEY_F3
+	seqparser.KeyF4:        62,  // KEY_F4
+}
+
+// seqModifierKeys maps the Kitty keyboard protocol's private-use-area
+// codepoints for a standalone modifier keypress (Shift/Ctrl/Alt/Super
+// pressed or held with no other key) to the corresponding evdev keycode.
+// Without this, a lone modifier never matches km.Lookup and translateSeqKey
+// silently drops it, so holding Shift alone for a drag never reaches the
+// host. See the Kitty keyboard protocol spec's table of functional key
+// codes.
+var seqModifierKeys = map[rune]uint16{
+	57441: evdev.KeyLeftShift,
+	57442: evdev.KeyLeftCtrl,
+	57443: evdev.KeyLeftAlt,
+	57444: evdev.KeyLeftMeta,
+	57447: evdev.KeyRightShift,
+	57448: evdev.KeyRightCtrl,
+	57449: evdev.KeyRightAlt,
+	57450: evdev.KeyRightMeta,
+}
+
+// translateSeqKey resolves a seqparser.KeyEvent to the evdev keycode and
+// modifier bitmask needed to reproduce it via km.
+func translateSeqKey(ev seqparser.KeyEvent, km *hidg.Keymap) (code uint16, mods byte, ok bool) {
+	if code, found := seqModifierKeys[ev.Code]; found {
+		// The key itself is the modifier; it doesn't need the chorded
+		// mods forwarding below, which exists for modifiers held
+		// alongside some other key.
+		return code, 0, true
+	}
+
+	if c, found := seqSpecialKeys[ev.Code]; found {
+		code, ok = c, true
+	} else {
+		kd, found := km.Lookup(ev.Code)
+		if !found && ev.Code >= 'A' && ev.Code <= 'Z' {
+			kd, found = km.Lookup(ev.Code + ('a' - 'A'))
+			mods |= 0x02 // left-shift
+		}
+		if !found {
+			return 0, 0, false
+		}
+		code, mods, ok = kd.Code, mods|kd.Mods, true
+	}
+
+	if ev.Mods&seqparser.ModShift != 0 {
+		mods |= 0x02
+	}
+	if ev.Mods&seqparser.ModCtrl != 0 {
+		mods |= 0x01
+	}
+	if ev.Mods&seqparser.ModAlt != 0 {
+		mods |= 0x04
+	}
+	if ev.Mods&seqparser.ModSuper != 0 {
+		mods |= 0x08
+	}
+	return code, mods, ok
+}
+
+// runSeqParser reads raw stdin bytes through the Kitty-protocol-aware
+// seqparser instead of tcell, so held keys produce a HID key-up at the
+// moment they're actually released instead of immediately after the
+// key-down. It returns seqparser.ErrUnsupported if the terminal never
+// answered the capability probe, so the caller can fall back to the
+// tcell-based UI.
+func runSeqParser(hid *hidg.UsbHid, km *hidg.Keymap) error {
+	restore, err := seqparser.RawMode(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer restore()
+	defer seqparser.DisableKittyProtocol(os.Stdout)
+
+	reader := seqparser.NewReader(os.Stdin)
+	if !reader.ProbeSupport(os.Stdout, 200*time.Millisecond) {
+		return seqparser.ErrUnsupported
+	}
+
+	fmt.Println("hidg: Kitty keyboard protocol active, press Escape twice to exit.")
+
+	events := make(chan seqparser.KeyEvent)
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- reader.Run(events)
+		close(events)
+	}()
+
+	escCount := 0
+	for ev := range events {
+		if ev.Code == seqparser.KeyEscape {
+			if ev.Action == seqparser.Press {
+				escCount++
+				if escCount > 1 {
+					return nil
+				}
+			}
+			continue
+		}
+		escCount = 0
+
+		code, mods, ok := translateSeqKey(ev, km)
+		if !ok {
+			continue
+		}
+
+		switch ev.Action {
+		case seqparser.Press:
+			for _, m := range tcellkeys.ModKeys {
+				if mods&m.Bit != 0 {
+					hid.ForwardEvent(evdev.Event{Type: evdev.EvKeys, Code: m.Code, Value: 1})
+				}
+			}
+			hid.ForwardEvent(evdev.Event{Type: evdev.EvKeys, Code: code, Value: 1})
+		case seqparser.Release:
+			hid.ForwardEvent(evdev.Event{Type: evdev.EvKeys, Code: code, Value: 0})
+			for _, m := range tcellkeys.ModKeys {
+				if mods&m.Bit != 0 {
+					hid.ForwardEvent(evdev.Event{Type: evdev.EvKeys, Code: m.Code, Value: 0})
+				}
+			}
+		case seqparser.Repeat:
+			// The key is already held down; a boot keyboard report has
+			// nothing new to say until it's released.
+		}
+	}
+
+	return <-readErr
+}
+
+// loadKeymap resolves name as a built-in keymap (us, de, fr, ...) and
+// falls back to treating it as a path to a keymap file on disk.
+func loadKeymap(name string) (*hidg.Keymap, error) {
+	if km, err := keymaps.Load(name); err == nil {
+		return km, nil
+	}
+	return hidg.LoadKeymap(name)
+}
+
+var defStyle tcell.Style
+
+func emitStr(s tcell.Screen, x, y int, style tcell.Style, str string) {
+	for _, c := range str {
+		var comb []rune
+		w := runewidth.RuneWidth(c)
+		if w == 0 {
+			comb = []rune{c}
+			c = ' '
+			w = 1
+		}
+		s.SetContent(x, y, c, comb, style)
+		x += w
+	}
+}
+
+func drawBox(s tcell.Screen, x1, y1, x2, y2 int, style tcell.Style, r rune) {
+	if y2 < y1 {
+		y1, y2 = y2, y1
+	}
+	if x2 < x1 {
+		x1, x2 = x2, x1
+	}
+
+	for col := x1; col <= x2; col++ {
+		s.SetContent(col, y1, tcell.RuneHLine, nil, style)
+		s.SetContent(col, y2, tcell.RuneHLine, nil, style)
+	}
+	for row := y1 + 1; row < y2; row++ {
+		s.SetContent(x1, row, tcell.RuneVLine, nil, style)
+		s.SetContent(x2, row, tcell.RuneVLine, nil, style)
+	}
+	if y1 != y2 && x1 != x2 {
+		// Only add corners if we need to
+		s.SetContent(x1, y1, tcell.RuneULCorner, nil, style)
+		s.SetContent(x2, y1, tcell.RuneURCorner, nil, style)
+		s.SetContent(x1, y2, tcell.RuneLLCorner, nil, style)
+		s.SetContent(x2, y2, tcell.RuneLRCorner, nil, style)
+	}
+	for row := y1 + 1; row < y2; row++ {
+		for col := x1 + 1; col < x2; col++ {
+			s.SetContent(col, row, r, nil, style)
+		}
+	}
+}
+
+func drawSelect(s tcell.Screen, x1, y1, x2, y2 int, sel bool) {
+
+	if y2 < y1 {
+		y1, y2 = y2, y1
+	}
+	if x2 < x1 {
+		x1, x2 = x2, x1
+	}
+	for row := y1; row <= y2; row++ {
+		for col := x1; col <= x2; col++ {
+			mainc, combc, style, width := s.GetContent(col, row)
+			if style == tcell.StyleDefault {
+				style = defStyle
+			}
+			style = style.Reverse(sel)
+			s.SetContent(col, row, mainc, combc, style)
+			col += width - 1
+		}
+	}
+}
+
+// This program just shows simple mouse and keyboard events.  Press ESC twice to
+// exit.
+func main() {
+	keymapFlag := flag.String("keymap", "us", "keymap to use: a built-in name (us, us-dvorak, de, fr, uk) or a path to a keymap file")
+	flag.Parse()
+
+	km, err := loadKeymap(*keymapFlag)
+	if err != nil {
+		fmt.Println("ERROR: Could not load keymap ", *keymapFlag, ":", err)
+		return
+	}
+
+	hid, err := hidg.Open("/dev/hidg0", km)
+	if err != nil {
+		fmt.Println("ERROR: Could not open ", "/dev/hidg0")
+		return
+	}
+	defer hid.Close()
+
+	mouse, err := hidg.OpenMouse("/dev/hidg1", hidg.CellToPixel)
+	if err != nil {
+		fmt.Println("ERROR: Could not open ", "/dev/hidg1")
+		return
+	}
+	defer mouse.Close()
+
+	consumer, err := hidg.OpenConsumer("/dev/hidg2")
+	if err != nil {
+		fmt.Println("ERROR: Could not open ", "/dev/hidg2")
+		return
+	}
+	defer consumer.Close()
+	hid.AttachConsumer(consumer)
+
+	if runtime.GOOS != "windows" && term.IsTerminal(int(os.Stdin.Fd())) {
+		err := runSeqParser(hid, km)
+		if err == nil {
+			return
+		}
+		if err != seqparser.ErrUnsupported {
+			fmt.Println("hidg: sequence-parser input failed, falling back to tcell:", err)
+		}
+	}
+
+	encoding.Register()
+
+	s, e := tcell.NewScreen()
+	if e != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", e)
+		os.Exit(1)
+	}
+	if e := s.Init(); e != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", e)
+		os.Exit(1)
+	}
+	defStyle = tcell.StyleDefault.
+		Background(tcell.ColorBlack).
+		Foreground(tcell.ColorWhite)
+	s.SetStyle(defStyle)
+	s.EnableMouse()
+	s.Clear()
+
+	posfmt := "Mouse: %d, %d  "
+	btnfmt := "Buttons: %s"
+	keyfmt := "Keys: %s"
+	white := tcell.StyleDefault.
+		Foreground(tcell.ColorWhite).Background(tcell.ColorRed)
+
+	mx, my := -1, -1
+	ox, oy := -1, -1
+	bx, by := -1, -1
+	w, h := s.Size()
+	lchar := '*'
+	bstr := ""
+	lks := ""
+	ecnt := 0
+
+	for {
+		drawBox(s, 1, 1, 42, 6, white, ' ')
+		emitStr(s, 2, 2, white, "Press ESC twice to exit, C to clear.")
+		emitStr(s, 2, 3, white, fmt.Sprintf(posfmt, mx, my))
+		emitStr(s, 2, 4, white, fmt.Sprintf(btnfmt, bstr))
+		emitStr(s, 2, 5, white, fmt.Sprintf(keyfmt, lks))
+
+		s.Show()
+		bstr = ""
+		ev := s.PollEvent()
+		st := tcell.StyleDefault.Background(tcell.ColorRed)
+		up := tcell.StyleDefault.
+			Background(tcell.ColorBlue).
+			Foreground(tcell.ColorBlack)
+		w, h = s.Size()
+
+		// always clear any old selection box
+		if ox >= 0 && oy >= 0 && bx >= 0 {
+			drawSelect(s, ox, oy, bx, by, false)
+		}
+
+		switch ev := ev.(type) {
+		case *tcell.EventResize:
+			s.Sync()
+			s.SetContent(w-1, h-1, 'R', nil, st)
+		case *tcell.EventKey:
+			key, mods, ok := tcellkeys.Translate(ev, km)
+			if !ok {
+				fmt.Printf("Warning: No keymap entry for %q\n", ev.Rune())
+			} else {
+				for _, m := range tcellkeys.ModKeys {
+					if mods&m.Bit != 0 {
+						hid.ForwardEvent(evdev.Event{Type: evdev.EvKeys, Code: m.Code, Value: 1})
+					}
+				}
+				hid.ForwardEvent(evdev.Event{Type: evdev.EvKeys, Code: key, Value: 1})
+				hid.ForwardEvent(evdev.Event{Type: evdev.EvKeys, Code: key, Value: 0})
+				for _, m := range tcellkeys.ModKeys {
+					if mods&m.Bit != 0 {
+						hid.ForwardEvent(evdev.Event{Type: evdev.EvKeys, Code: m.Code, Value: 0})
+					}
+				}
+			}
+
+			s.SetContent(w-2, h-2, ev.Rune(), nil, st)
+			s.SetContent(w-1, h-1, 'K', nil, st)
+			if ev.Key() == tcell.KeyEscape {
+				ecnt++
+				if ecnt > 1 {
+					s.Fini()
+					os.Exit(0)
+				}
+			} else if ev.Key() == tcell.KeyCtrlL {
+				s.Sync()
+			} else {
+				ecnt = 0
+				if ev.Rune() == 'C' || ev.Rune() == 'c' {
+					s.Clear()
+				}
+			}
+			lks = ev.Name()
+		case *tcell.EventMouse:
+			mouse.ForwardMouse(ev)
+			x, y := ev.Position()
+			button := ev.Buttons()
+			for i := uint(0); i < 8; i++ {
+				if int(button)&(1<<i) != 0 {
+					bstr += fmt.Sprintf(" Button%d", i+1)
+				}
+			}
+			if button&tcell.WheelUp != 0 {
+				bstr += " WheelUp"
+			}
+			if button&tcell.WheelDown != 0 {
+				bstr += " WheelDown"
+			}
+			if button&tcell.WheelLeft != 0 {
+				bstr += " WheelLeft"
+			}
+			if button&tcell.WheelRight != 0 {
+				bstr += " WheelRight"
+			}
+			// Only buttons, not wheel events
+			button &= tcell.ButtonMask(0xff)
+			ch := '*'
+
+			if button != tcell.ButtonNone && ox < 0 {
+				ox, oy = x, y
+			}
+			switch ev.Buttons() {
+			case tcell.ButtonNone:
+				if ox >= 0 {
+					bg := tcell.Color((lchar - '0') * 2)
+					drawBox(s, ox, oy, x, y,
+						up.Background(bg),
+						lchar)
+					ox, oy = -1, -1
+					bx, by = -1, -1
+				}
+			case tcell.Button1:
+				ch = '1'
+			case tcell.Button2:
+				ch = '2'
+			case tcell.Button3:
+				ch = '3'
+			case tcell.Button4:
+				ch = '4'
+			case tcell.Button5:
+				ch = '5'
+			case tcell.Button6:
+				ch = '6'
+			case tcell.Button7:
+				ch = '7'
+			case tcell.Button8:
+				ch = '8'
+			default:
+				ch = '*'
+
+			}
+			if button != tcell.ButtonNone {
+				bx, by = x, y
+			}
+			lchar = ch
+			s.SetContent(w-1, h-1, 'M', nil, st)
+			mx, my = x, y
+		default:
+			s.SetContent(w-1, h-1, 'X', nil, st)
+		}
+
+		if ox >= 0 && bx >= 0 {
+			drawSelect(s, ox, oy, bx, by, true)
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package hidg
+
+import (
+	"fmt"
+	"os"
+)
+
+// consumerUsage maps evdev key codes for media keys to their USB HID
+// Consumer Page (0x0C) usage IDs, per the HID Usage Tables spec.
+var consumerUsage = map[uint16]uint16{
+	115: 0x00E9, // KEY_VOLUMEUP
+	114: 0x00EA, // KEY_VOLUMEDOWN
+	113: 0x00E2, // KEY_MUTE
+	164: 0x00CD, // KEY_PLAYPAUSE
+	163: 0x00B5, // KEY_NEXTSONG
+	165: 0x00B6, // KEY_PREVIOUSSONG
+	166: 0x00B7, // KEY_STOPCD
+	224: 0x0070, // KEY_BRIGHTNESSDOWN
+	225: 0x006F, // KEY_BRIGHTNESSUP
+	158: 0x0224, // KEY_BACK
+	159: 0x0225, // KEY_FORWARD
+}
+
+// ConsumerUsage returns the Consumer Page usage ID for an evdev key code,
+// and whether one is defined. UsbHid.updateReport uses it to route media
+// keys to an attached UsbConsumer instead of just warning that the
+// keyboard usage page has no mapping for them.
+func ConsumerUsage(code uint16) (uint16, bool) {
+	usage, ok := consumerUsage[code]
+	return usage, ok
+}
+
+type consumerEvent struct {
+	usage   uint16
+	pressed bool
+}
+
+// UsbConsumer writes 2-byte Consumer Control reports (a single 16-bit
+// usage ID, 0 when nothing is pressed) to a HID gadget endpoint for the
+// Consumer Page, the usage page media keys live on rather than the
+// keyboard's.
+type UsbConsumer struct {
+	ev   chan consumerEvent
+	exit chan bool
+	file *os.File
+}
+
+// OpenConsumer opens the HID consumer control gadget endpoint at path and
+// starts its report writer.
+func OpenConsumer(path string) (*UsbConsumer, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(UsbConsumer)
+	c.ev = make(chan consumerEvent)
+	c.exit = make(chan bool)
+	c.file = file
+
+	go consumerWriter(c)
+
+	return c, nil
+}
+
+func (c *UsbConsumer) Close() {
+	c.exit <- true
+}
+
+// ForwardConsumer reports usage as pressed or released on the Consumer
+// Control interface.
+func (c *UsbConsumer) ForwardConsumer(usage uint16, pressed bool) {
+	c.ev <- consumerEvent{usage: usage, pressed: pressed}
+}
+
+func consumerWriter(c *UsbConsumer) {
+	defer c.file.Close()
+
+	var report [2]byte
+	for {
+		select {
+		case ev := <-c.ev:
+			if ev.pressed {
+				report[0] = byte(ev.usage)
+				report[1] = byte(ev.usage >> 8)
+			} else {
+				report[0] = 0
+				report[1] = 0
+			}
+			n, _ := c.file.Write(report[:])
+			if n != len(report) {
+				fmt.Println("ERROR: Write failed")
+				return
+			}
+			c.file.Sync()
+		case <-c.exit:
+			return
+		}
+	}
+}
@@ -0,0 +1,33 @@
+// Package keymaps ships the built-in keyboard layouts (us, us-dvorak,
+// de, fr, uk) that cmd/hidg's --keymap flag can select by name, so most
+// users never need to hand-write a keymap file.
+package keymaps
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+	"github.com/grimpy/hidg"
+)
+
+//go:embed *.toml
+var builtin embed.FS
+
+// Names lists the built-in keymaps available to --keymap.
+var Names = []string{"us", "us-dvorak", "de", "fr", "uk"}
+
+// Load returns the built-in keymap registered under name (e.g. "us",
+// "de"), without the .toml suffix.
+func Load(name string) (*hidg.Keymap, error) {
+	data, err := builtin.ReadFile(name + ".toml")
+	if err != nil {
+		return nil, fmt.Errorf("unknown built-in keymap %q", name)
+	}
+
+	km := new(hidg.Keymap)
+	if _, err := toml.Decode(string(data), km); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
@@ -0,0 +1,58 @@
+package seqparser
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// enableKitty is CSI > 3 u: push progressive-enhancement bits 0 and 1
+// ("disambiguate escape codes" | "report event types") onto the
+// terminal's keyboard mode stack. Bit 0 alone only disambiguates which
+// key was pressed; bit 1 is what actually makes the terminal emit the
+// Repeat/Release sub-parameter decodeAction depends on - without it
+// every event decodes as Press.
+const enableKitty = "\x1b[>3u"
+
+// disableKitty pops the flag pushed by enableKitty, restoring whatever
+// keyboard mode the terminal had before.
+const disableKitty = "\x1b[<1u"
+
+// da1Query is "Primary Device Attributes": every real terminal answers
+// it with a CSI ... c response, which is used here purely as a timed
+// probe for "is anything dequeuing and answering our escape sequences".
+const da1Query = "\x1b[c"
+
+// EnableKittyProtocol writes the sequence that turns on the Kitty
+// keyboard protocol.
+func EnableKittyProtocol(w io.Writer) error {
+	_, err := io.WriteString(w, enableKitty)
+	return err
+}
+
+// DisableKittyProtocol writes the sequence that turns the Kitty keyboard
+// protocol back off; callers should defer this after a successful
+// EnableKittyProtocol.
+func DisableKittyProtocol(w io.Writer) error {
+	_, err := io.WriteString(w, disableKitty)
+	return err
+}
+
+// ProbeSupport writes a DA1 query (after requesting the Kitty protocol)
+// to w and waits up to timeout for any response on rd's underlying
+// stream. It returns true only if a response arrives, meaning the
+// terminal is actually parsing our escape sequences; callers should fall
+// back to the tcell-based UI otherwise.
+//
+// rd owns the one background goroutine that reads its stream for rd's
+// entire lifetime, so any bytes read during the probe - whether the DA1
+// response itself or a keystroke that raced it - are fed into rd's
+// parser rather than discarded: a later call to rd.Run emits them before
+// reading anything new.
+func (rd *Reader) ProbeSupport(w io.Writer, timeout time.Duration) bool {
+	return rd.Probe(w, enableKitty+da1Query, timeout)
+}
+
+// ErrUnsupported is returned by callers that decide not to use the
+// sequence-parser front-end after a failed ProbeSupport.
+var ErrUnsupported = fmt.Errorf("seqparser: terminal did not respond to capability probe")
@@ -0,0 +1,200 @@
+package seqparser
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ss3Keys maps the final byte of an SS3 sequence (ESC O <byte>) to the
+// key it identifies: the VT100 F1-F4 sequences. Other SS3 sequences are
+// consumed without producing a KeyEvent.
+var ss3Keys = map[byte]rune{
+	'P': KeyF1,
+	'Q': KeyF2,
+	'R': KeyF3,
+	'S': KeyF4,
+}
+
+// csiLetterKeys maps the final byte of a no-parameter-code CSI cursor
+// sequence (ESC [ [1;<mods>[:<event>]] <byte>) to the key it identifies.
+var csiLetterKeys = map[byte]rune{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+}
+
+// vtTildeKeys maps the numeric parameter of a CSI ... ~ sequence to the
+// key it identifies (the subset this front-end cares about).
+var vtTildeKeys = map[int]rune{
+	1: KeyHome,
+	4: KeyEnd,
+}
+
+// parseOne attempts to decode a single KeyEvent from the front of buf. It
+// returns the number of bytes consumed (0 means "need more data, don't
+// consume anything yet") and whether a KeyEvent was produced (some
+// sequences, like a lone CSI with no recognized key, are consumed but
+// produce nothing).
+func parseOne(buf []byte) (int, KeyEvent, bool) {
+	if buf[0] != esc {
+		return parseCharacter(buf)
+	}
+
+	if len(buf) < 2 {
+		// Could be a lone Escape keypress or the start of a sequence;
+		// the reader loop resolves the ambiguity with FlushEscape after
+		// a short timeout.
+		return 0, KeyEvent{}, false
+	}
+
+	switch buf[1] {
+	case '[':
+		return parseCSI(buf)
+	case 'O':
+		if len(buf) < 3 {
+			return 0, KeyEvent{}, false
+		}
+		code, ok := ss3Keys[buf[2]]
+		if !ok {
+			return 3, KeyEvent{}, false
+		}
+		return 3, KeyEvent{Code: code, Action: Press}, true
+	default:
+		// An unrecognized ESC-prefixed sequence (e.g. Alt+key sends
+		// ESC followed by the character); treat it as Alt + that byte.
+		n, ev, ok := parseCharacter(buf[1:])
+		if n == 0 {
+			return 0, KeyEvent{}, false
+		}
+		ev.Mods |= ModAlt
+		return n + 1, ev, ok
+	}
+}
+
+// parseCharacter decodes a single C0 control code or UTF-8 rune from the
+// front of buf.
+func parseCharacter(buf []byte) (int, KeyEvent, bool) {
+	switch buf[0] {
+	case '\r', '\n':
+		return 1, KeyEvent{Code: KeyEnter, Action: Press}, true
+	case '\t':
+		return 1, KeyEvent{Code: KeyTab, Action: Press}, true
+	case 0x7f, 0x08:
+		return 1, KeyEvent{Code: KeyBackspace, Action: Press}, true
+	}
+
+	if buf[0] < 0x20 {
+		// Other C0 controls are Ctrl+<letter>: Ctrl-A is 0x01, etc.
+		return 1, KeyEvent{Code: rune('a' + buf[0] - 1), Mods: ModCtrl, Action: Press}, true
+	}
+
+	r, size := utf8.DecodeRune(buf)
+	if r == utf8.RuneError && size <= 1 {
+		if len(buf) < 4 {
+			// Might be a truncated multi-byte rune; wait for more.
+			return 0, KeyEvent{}, false
+		}
+		return 1, KeyEvent{}, false
+	}
+	return size, KeyEvent{Code: r, Action: Press}, true
+}
+
+// parseCSI decodes ESC [ <params> <final>, where <params> is a
+// semicolon-separated list of (possibly colon-separated) numbers.
+func parseCSI(buf []byte) (int, KeyEvent, bool) {
+	i := 2
+	for i < len(buf) && (buf[i] >= 0x30 && buf[i] <= 0x3f) {
+		i++
+	}
+	if i >= len(buf) {
+		return 0, KeyEvent{}, false // params not terminated yet
+	}
+	final := buf[i]
+	if final < 0x40 || final > 0x7e {
+		return 0, KeyEvent{}, false
+	}
+
+	params := strings.Split(string(buf[2:i]), ";")
+	consumed := i + 1
+
+	switch final {
+	case 'u':
+		return consumed, decodeKittyU(params), true
+	case '~':
+		code, ok := vtTildeKeys[firstInt(params, 0, 0)]
+		if !ok {
+			return consumed, KeyEvent{}, false
+		}
+		return consumed, KeyEvent{Code: code, Mods: decodeMods(params, 1), Action: decodeAction(params, 1)}, true
+	default:
+		if code, ok := csiLetterKeys[final]; ok {
+			return consumed, KeyEvent{Code: code, Mods: decodeMods(params, 1), Action: decodeAction(params, 1)}, true
+		}
+		return consumed, KeyEvent{}, false
+	}
+}
+
+// decodeKittyU decodes the Kitty protocol's "CSI <code>[:...][;<mods>[:<event>]] u"
+// form into a KeyEvent.
+func decodeKittyU(params []string) KeyEvent {
+	code := rune(firstInt(splitSub(params, 0), 0, 0))
+	ev := KeyEvent{
+		Code:   code,
+		Mods:   decodeMods(params, 1),
+		Action: decodeAction(params, 1),
+	}
+	// Kitty reports Escape as "CSI 27 u" rather than the bare 0x1b byte,
+	// so it never hits the lone-ESC path in parse.go; normalize it to the
+	// same sentinel FlushEscape uses.
+	if code == 27 {
+		ev.Code = KeyEscape
+	}
+	return ev
+}
+
+// decodeMods reads the xterm/Kitty modifier parameter at params[idx],
+// which is encoded as 1+bitmask(shift=1,alt=2,ctrl=4,super=8).
+func decodeMods(params []string, idx int) Mods {
+	sub := splitSub(params, idx)
+	v := firstInt(sub, 0, 1)
+	if v <= 1 {
+		return 0
+	}
+	return Mods(v - 1)
+}
+
+// decodeAction reads the Kitty event-type sub-parameter of params[idx],
+// if present (1=press, 2=repeat, 3=release); absent means Press.
+func decodeAction(params []string, idx int) Action {
+	sub := splitSub(params, idx)
+	switch firstInt(sub, 1, 1) {
+	case 2:
+		return Repeat
+	case 3:
+		return Release
+	default:
+		return Press
+	}
+}
+
+func splitSub(params []string, idx int) []string {
+	if idx >= len(params) || params[idx] == "" {
+		return nil
+	}
+	return strings.Split(params[idx], ":")
+}
+
+func firstInt(parts []string, pos int, def int) int {
+	if pos >= len(parts) || parts[pos] == "" {
+		return def
+	}
+	n, err := strconv.Atoi(parts[pos])
+	if err != nil {
+		return def
+	}
+	return n
+}
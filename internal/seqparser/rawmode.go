@@ -0,0 +1,17 @@
+package seqparser
+
+import (
+	"golang.org/x/term"
+)
+
+// RawMode puts fd (typically os.Stdin.Fd()) into raw mode so that bytes
+// reach the process one at a time, unprocessed, instead of being
+// line-buffered and echoed by the tty driver. Call the returned restore
+// function to put the terminal back the way it was.
+func RawMode(fd int) (restore func() error, err error) {
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	return func() error { return term.Restore(fd, state) }, nil
+}
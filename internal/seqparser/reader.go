@@ -0,0 +1,115 @@
+package seqparser
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// escTimeout is how long Reader.Run waits for more bytes after a lone
+// ESC before deciding it was a standalone Escape keypress rather than
+// the start of a cut-off sequence.
+const escTimeout = 25 * time.Millisecond
+
+// Reader drives a Parser from a raw byte stream (normally os.Stdin put
+// into raw mode via RawMode). It owns a single background goroutine that
+// reads from the stream for the lifetime of the Reader, so Probe and Run
+// can share it instead of racing separate one-shot reads against each
+// other and losing bytes read in between.
+type Reader struct {
+	r       io.Reader
+	parser  Parser
+	pending []KeyEvent
+
+	startOnce sync.Once
+	reads     chan readResult
+}
+
+// NewReader returns a Reader that will read from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: r, reads: make(chan readResult)}
+}
+
+type readResult struct {
+	data []byte
+	err  error
+}
+
+// start launches the background read loop exactly once, however many
+// times Probe/Run are called.
+func (rd *Reader) start() {
+	rd.startOnce.Do(func() {
+		go func() {
+			buf := make([]byte, 256)
+			for {
+				n, err := rd.r.Read(buf)
+				var chunk []byte
+				if n > 0 {
+					chunk = append([]byte(nil), buf[:n]...)
+				}
+				rd.reads <- readResult{chunk, err}
+				if err != nil {
+					return
+				}
+			}
+		}()
+	})
+}
+
+// Probe writes query to w and waits up to timeout for a response read
+// from the underlying stream, for detecting whether the terminal
+// actually parses the escape sequences this package writes to it. Any
+// bytes read (whether they're the probe response or something else,
+// like a keystroke that raced it) are fed into the parser so a
+// subsequent Run recovers them instead of silently dropping them.
+func (rd *Reader) Probe(w io.Writer, query string, timeout time.Duration) bool {
+	rd.start()
+
+	if _, err := io.WriteString(w, query); err != nil {
+		return false
+	}
+
+	select {
+	case res := <-rd.reads:
+		if len(res.data) > 0 {
+			rd.pending = append(rd.pending, rd.parser.Feed(res.data)...)
+		}
+		return res.err == nil && len(res.data) > 0 && bytes.IndexByte(res.data, esc) >= 0
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// Run reads from the underlying reader until it errors (e.g. the
+// terminal is closed) and sends every decoded KeyEvent to out, starting
+// with anything buffered by a prior call to Probe.
+func (rd *Reader) Run(out chan<- KeyEvent) error {
+	rd.start()
+
+	for _, ev := range rd.pending {
+		out <- ev
+	}
+	rd.pending = nil
+
+	for {
+		var timeout <-chan time.Time
+		if rd.parser.Pending() {
+			timeout = time.After(escTimeout)
+		}
+
+		select {
+		case res := <-rd.reads:
+			for _, ev := range rd.parser.Feed(res.data) {
+				out <- ev
+			}
+			if res.err != nil {
+				return res.err
+			}
+		case <-timeout:
+			if ev, ok := rd.parser.FlushEscape(); ok {
+				out <- ev
+			}
+		}
+	}
+}
@@ -0,0 +1,103 @@
+// Package seqparser is a small ANSI/Kitty keyboard-protocol parser
+// modeled on the terminal input reimplementation done for Bubble Tea. It
+// turns raw stdin bytes into KeyEvents with real Press/Repeat/Release
+// actions and exact modifier state, instead of the normalized, press-only
+// events tcell hands back. That's what lets a held key produce a HID
+// key-up at the moment it's actually released rather than immediately
+// after the key-down, which tcell's input layer can't express.
+package seqparser
+
+// Action is what happened to a key: it is only ever Repeat or Release
+// when the terminal has the Kitty keyboard protocol enabled; a plain
+// xterm-style terminal only ever reports Press.
+type Action int
+
+const (
+	Press Action = iota
+	Repeat
+	Release
+)
+
+// Mods is a bitmask of held modifier keys, decoded from the CSI modifier
+// parameter (1 + bitmask, per the xterm/Kitty convention).
+type Mods uint8
+
+const (
+	ModShift Mods = 1 << iota
+	ModAlt
+	ModCtrl
+	ModSuper
+)
+
+// Special, non-printable keys are represented as negative runes so they
+// can't collide with an actual Unicode code point in KeyEvent.Code.
+const (
+	KeyUp rune = -(iota + 1)
+	KeyDown
+	KeyLeft
+	KeyRight
+	KeyHome
+	KeyEnd
+	KeyF1
+	KeyF2
+	KeyF3
+	KeyF4
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyEscape
+)
+
+// KeyEvent is one decoded keypress: Code is either a printable rune or
+// one of the Key* constants above, Mods is the held modifiers, and
+// Action says whether this is a press, an autorepeat, or a release.
+type KeyEvent struct {
+	Code   rune
+	Mods   Mods
+	Action Action
+}
+
+const esc = 0x1b
+
+// Parser accumulates raw terminal bytes and decodes complete escape
+// sequences out of them. It is not safe for concurrent use.
+type Parser struct {
+	buf []byte
+}
+
+// Feed appends data to the parser's buffer and returns every KeyEvent
+// that could be fully decoded from it. Bytes belonging to a sequence
+// that hasn't arrived in full yet are kept for the next call.
+func (p *Parser) Feed(data []byte) []KeyEvent {
+	p.buf = append(p.buf, data...)
+
+	var events []KeyEvent
+	for len(p.buf) > 0 {
+		n, ev, ok := parseOne(p.buf)
+		if n == 0 {
+			break
+		}
+		p.buf = p.buf[n:]
+		if ok {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+// Pending reports whether the parser is holding an unparsed escape
+// sequence, waiting for more bytes.
+func (p *Parser) Pending() bool {
+	return len(p.buf) > 0
+}
+
+// FlushEscape is called by the reader loop after a short read timeout:
+// a lone, still-buffered ESC byte with nothing following it is a real
+// Escape keypress rather than the start of a sequence that got cut off.
+func (p *Parser) FlushEscape() (KeyEvent, bool) {
+	if len(p.buf) == 1 && p.buf[0] == esc {
+		p.buf = nil
+		return KeyEvent{Code: KeyEscape, Action: Press}, true
+	}
+	return KeyEvent{}, false
+}
@@ -0,0 +1,78 @@
+// Package tcellkeys turns a tcell key event into an evdev keycode plus
+// modifier bitmask, via a hidg.Keymap. It's shared by cmd/hidg (which
+// also draws a terminal UI around it) and cmd/hidg-remote (which only
+// needs the translation, not the UI).
+package tcellkeys
+
+import (
+	"github.com/gdamore/tcell"
+	"github.com/grimpy/hidg"
+	"github.com/maugsburger/evdev"
+)
+
+// NonPrintable holds the tcell keys that aren't layout-dependent
+// characters: every keyboard layout has the same physical Enter, Tab,
+// Backspace and arrow keys.
+var NonPrintable = map[rune]uint16{
+	rune(tcell.KeyBackspace2): evdev.KeyBackSpace,
+	rune(tcell.KeyBackspace):  evdev.KeyBackSpace,
+	rune(tcell.KeyEnter):      evdev.KeyEnter,
+	rune(tcell.KeyUp):         evdev.KeyUp,
+	rune(tcell.KeyDown):       evdev.KeyDown,
+	rune(tcell.KeyLeft):       evdev.KeyLeft,
+	rune(tcell.KeyRight):      evdev.KeyRight,
+	rune(tcell.KeyTAB):        evdev.KeyTab,
+	rune(tcell.KeyEsc):        evdev.KeyEscape,
+}
+
+// ModKeys maps a Keymap modifier bit to the evdev code of the
+// corresponding left-hand modifier key.
+var ModKeys = []struct {
+	Bit  byte
+	Code uint16
+}{
+	{0x01, evdev.KeyLeftCtrl},
+	{0x02, evdev.KeyLeftShift},
+	{0x04, evdev.KeyLeftAlt},
+	{0x08, evdev.KeyLeftMeta},
+}
+
+// ctrlToLetter turns the control character tcell reports for Ctrl+<letter>
+// (ASCII 1-26) back into the plain letter, so it can be looked up in the
+// keymap like any other key.
+func ctrlToLetter(r rune) (rune, bool) {
+	if r >= 1 && r <= 26 {
+		return rune('a' + r - 1), true
+	}
+	return 0, false
+}
+
+// Translate resolves a tcell key event to the evdev keycode and modifier
+// bitmask needed to reproduce it via km, the same precedence cmd/hidg's
+// UI uses: non-printable keys first, then Ctrl+letter, then the keymap's
+// shifted/plain rune tables.
+func Translate(ev *tcell.EventKey, km *hidg.Keymap) (code uint16, mods byte, ok bool) {
+	pressedkey := ev.Rune()
+
+	if c, found := NonPrintable[pressedkey]; found {
+		return c, 0, true
+	}
+
+	if ev.Modifiers()&tcell.ModCtrl != 0 {
+		if letter, isCtrl := ctrlToLetter(pressedkey); isCtrl {
+			pressedkey = letter
+		}
+		mods |= 0x01 // left-ctrl
+	}
+
+	kd, ok := km.Lookup(pressedkey)
+	if !ok && pressedkey >= 'A' && pressedkey <= 'Z' {
+		kd, ok = km.Lookup(pressedkey + ('a' - 'A'))
+		mods |= 0x02 // left-shift
+	}
+	if !ok {
+		return 0, 0, false
+	}
+
+	return kd.Code, mods | kd.Mods, true
+}
@@ -0,0 +1,50 @@
+package hidg
+
+import (
+	"github.com/BurntSushi/toml"
+)
+
+// KeyDef describes how to produce a single character on the target: the
+// evdev keycode to press, and the modifier bitmask (same encoding as
+// kb_mod) that must be held while pressing it.
+type KeyDef struct {
+	Code uint16 `toml:"code"`
+	Mods byte   `toml:"mods"`
+}
+
+// Keymap describes how runes typed into the terminal UI should be turned
+// into evdev keycodes for a particular host keyboard layout, replacing
+// the hardcoded US QWERTY tables. Keys holds the direct rune mapping;
+// Shifted holds pre-composed "already shifted" runes such as '!' so
+// callers don't have to special-case them. Mapping, if non-empty,
+// overrides the package-level scancode->HID-usage table for hosts with
+// an unusual keyboard.
+type Keymap struct {
+	Name    string            `toml:"name"`
+	Keys    map[string]KeyDef `toml:"keys"`
+	Shifted map[string]KeyDef `toml:"shifted"`
+	Mapping []byte            `toml:"mapping"`
+}
+
+// Lookup returns the KeyDef for r, checking the pre-composed shifted
+// table first, then the direct table, mirroring the precedence the old
+// tcellshiftokey/tcelltoev lookup had.
+func (km *Keymap) Lookup(r rune) (KeyDef, bool) {
+	if km == nil {
+		return KeyDef{}, false
+	}
+	if kd, ok := km.Shifted[string(r)]; ok {
+		return kd, true
+	}
+	kd, ok := km.Keys[string(r)]
+	return kd, ok
+}
+
+// LoadKeymap parses a keymap file in TOML format from path.
+func LoadKeymap(path string) (*Keymap, error) {
+	km := new(Keymap)
+	if _, err := toml.DecodeFile(path, km); err != nil {
+		return nil, err
+	}
+	return km, nil
+}
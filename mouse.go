@@ -0,0 +1,245 @@
+package hidg
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gdamore/tcell"
+	"github.com/maugsburger/evdev"
+)
+
+/*
+ * Boot Mouse report is 4 bytes: buttons, signed X delta, signed Y delta,
+ * signed wheel delta. tcell only hands us absolute cell coordinates, so
+ * UsbMouse keeps the last known position per screen and emits the
+ * relative motion instead.
+ */
+
+// CellToPixel is the default scale factor applied when converting a
+// one-cell mouse move into HID report counts. Terminal cells are much
+// larger than pixels, so a single cell of motion is spread out over
+// several report counts to keep cursor movement usable.
+const CellToPixel = 8
+
+// mouseBtnBit maps tcell.ButtonMask bits 0..7 (Button1..Button8) onto the
+// corresponding bit of the HID buttons byte. The boot mouse report only
+// defines Left/Right/Middle, but most hosts happily accept up to 8 buttons
+// in the same byte. tcell's Button2/Button3 (bits 1/2) are middle/right,
+// while the HID buttons byte defines bit1 as right and bit2 as middle, so
+// those two bits are swapped; the rest copy straight across.
+func mouseBtnBit(buttons tcell.ButtonMask) byte {
+	var b byte
+	for i := uint(0); i < 8; i++ {
+		if buttons&(1<<i) == 0 {
+			continue
+		}
+		switch i {
+		case 1:
+			b |= 1 << 2 // tcell middle -> HID bit2 (middle)
+		case 2:
+			b |= 1 << 1 // tcell right -> HID bit1 (right)
+		default:
+			b |= 1 << i
+		}
+	}
+	return b
+}
+
+type UsbMouse struct {
+	ev      chan *tcell.EventMouse
+	rawEv   chan evdev.Event
+	exit    chan bool
+	file    *os.File
+	report  [4]byte
+	scale   int
+	lastX   int
+	lastY   int
+	lastSet bool
+	remX    int
+	remY    int
+	lastBtn byte
+	rawRemX int
+	rawRemY int
+}
+
+// OpenMouse opens the HID boot mouse gadget endpoint at path (typically
+// /dev/hidg1) and starts its report writer. scale is the number of HID
+// motion counts emitted per terminal cell of movement; pass 0 to use
+// CellToPixel.
+func OpenMouse(path string, scale int) (*UsbMouse, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if scale <= 0 {
+		scale = CellToPixel
+	}
+
+	mouse := new(UsbMouse)
+	mouse.ev = make(chan *tcell.EventMouse)
+	mouse.rawEv = make(chan evdev.Event)
+	mouse.exit = make(chan bool)
+	mouse.file = file
+	mouse.scale = scale
+
+	go mouseWriter(mouse)
+
+	return mouse, nil
+}
+
+func (mouse *UsbMouse) Close() {
+	mouse.exit <- true
+}
+
+// ForwardMouse queues a tcell mouse event for translation into a HID
+// boot mouse report, mirroring UsbHid.ForwardEvent.
+func (mouse *UsbMouse) ForwardMouse(ev *tcell.EventMouse) {
+	mouse.ev <- ev
+}
+
+// mouseBtnFromEvdev maps evdev BTN_* mouse button codes to the bit of
+// the HID buttons byte they correspond to, mirroring kb_mod's modifier
+// table in hidg.go.
+var mouseBtnFromEvdev = map[uint16]byte{
+	evdev.BtnLeft:   0x01,
+	evdev.BtnRight:  0x02,
+	evdev.BtnMiddle: 0x04,
+	evdev.BtnSide:   0x08,
+	evdev.BtnExtra:  0x10,
+}
+
+// ForwardRawEvent queues a raw evdev mouse event - an EV_KEY BTN_* button
+// or EV_REL motion - from PassthroughMouseDevice for translation into a
+// HID boot mouse report. Unlike ForwardMouse, raw events are already
+// relative device counts rather than absolute terminal cells, so no
+// cell-to-pixel scaling is applied.
+func (mouse *UsbMouse) ForwardRawEvent(ev evdev.Event) {
+	mouse.rawEv <- ev
+}
+
+// clampDelta coalesces sub-cell motion: it folds the cell delta times the
+// configured scale into the running remainder and splits off whatever
+// fits in a signed byte, keeping the leftover for the next event so
+// fast, small drags aren't lost to rounding.
+func clampDelta(rem *int, cellDelta int, scale int) int8 {
+	*rem += cellDelta * scale
+	delta := *rem
+	if delta > 127 {
+		delta = 127
+	} else if delta < -127 {
+		delta = -127
+	}
+	*rem -= delta
+	return int8(delta)
+}
+
+// updateReport recomputes mouse.report from a tcell mouse event and
+// reports whether it should be written: a stationary drag report with no
+// button or wheel change is suppressed so it doesn't re-emit stale deltas.
+func (mouse *UsbMouse) updateReport(ev *tcell.EventMouse) bool {
+	x, y := ev.Position()
+	buttons := ev.Buttons()
+
+	if !mouse.lastSet {
+		mouse.lastX, mouse.lastY = x, y
+		mouse.lastSet = true
+	}
+
+	dx := x - mouse.lastX
+	dy := y - mouse.lastY
+	mouse.lastX, mouse.lastY = x, y
+
+	btn := mouseBtnBit(buttons & tcell.ButtonMask(0xff))
+
+	var wheel int8
+	switch {
+	case buttons&tcell.WheelUp != 0:
+		wheel = 1
+	case buttons&tcell.WheelDown != 0:
+		wheel = -1
+	case buttons&tcell.WheelLeft != 0:
+		wheel = -1
+	case buttons&tcell.WheelRight != 0:
+		wheel = 1
+	}
+
+	// Debounce: a drag (button held, mouse moving) that hasn't actually
+	// moved shouldn't emit a spurious report. Compare against the last
+	// *reported* button byte, not just "any button held", so a button
+	// press/release while stationary - e.g. adding Button2 while
+	// Button1 is still down - still gets its own report. A wheel tick
+	// always has dx==dy==0 and usually no button held, so it must bypass
+	// this guard too or scrolling would never reach the host.
+	if dx == 0 && dy == 0 && btn == mouse.lastBtn && wheel == 0 {
+		return false
+	}
+	mouse.lastBtn = btn
+
+	mouse.report[0] = btn
+	mouse.report[1] = byte(clampDelta(&mouse.remX, dx, mouse.scale))
+	mouse.report[2] = byte(clampDelta(&mouse.remY, dy, mouse.scale))
+	mouse.report[3] = byte(wheel)
+	return true
+}
+
+func (mouse *UsbMouse) updateRawReport(ev evdev.Event) {
+	// Each raw evdev event only describes one axis/button at a time (no
+	// EV_SYN batching here), so the motion/wheel bytes must be reset on
+	// every write: otherwise an axis that isn't part of this event would
+	// keep replaying whatever delta it last reported.
+	mouse.report[1] = 0
+	mouse.report[2] = 0
+	mouse.report[3] = 0
+
+	switch ev.Type {
+	case evdev.EvKeys:
+		if bit, ok := mouseBtnFromEvdev[ev.Code]; ok {
+			if ev.Value != 0 {
+				mouse.report[0] |= bit
+			} else {
+				mouse.report[0] &^= bit
+			}
+		}
+	case evdev.EvRelative:
+		switch ev.Code {
+		case evdev.RelX:
+			mouse.report[1] = byte(clampDelta(&mouse.rawRemX, int(ev.Value), 1))
+		case evdev.RelY:
+			mouse.report[2] = byte(clampDelta(&mouse.rawRemY, int(ev.Value), 1))
+		case evdev.RelWheel:
+			mouse.report[3] = byte(int8(ev.Value))
+		}
+	}
+}
+
+func mouseWriter(mouse *UsbMouse) {
+
+	defer mouse.file.Close()
+
+	writeReport := func() bool {
+		n, _ := mouse.file.Write(mouse.report[:])
+		if n != len(mouse.report) {
+			fmt.Println("ERROR: Write failed")
+			return false
+		}
+		mouse.file.Sync()
+		return true
+	}
+
+	for {
+		select {
+		case ev := <-mouse.ev:
+			if mouse.updateReport(ev) && !writeReport() {
+				return
+			}
+		case ev := <-mouse.rawEv:
+			mouse.updateRawReport(ev)
+			if !writeReport() {
+				return
+			}
+		case <-mouse.exit:
+			return
+		}
+	}
+}
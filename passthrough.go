@@ -0,0 +1,93 @@
+package hidg
+
+import (
+	"fmt"
+
+	"github.com/maugsburger/evdev"
+)
+
+// PassthroughReader opens a Linux evdev character device, grabs it
+// exclusively so its events stop reaching anything else on the system,
+// and streams its events straight into a HID gadget until the returned
+// reader is closed. Reading raw evdev preserves scancodes, relative
+// motion and press/release timing natively, so this bypasses the tcell
+// normalization that the terminal UI in cmd/hidg is subject to. A reader
+// is wired to exactly one gadget - a keyboard device forwards EvKeys to
+// a UsbHid, a mouse device forwards EvKeys button codes and EvRelative
+// motion to a UsbMouse - matching how hid.ForwardEvent and
+// mouse.ForwardMouse are each a single-purpose endpoint.
+type PassthroughReader struct {
+	device *evdev.Device
+	hid    *UsbHid
+	mouse  *UsbMouse
+	exit   chan struct{}
+}
+
+// PassthroughDevice opens path as a keyboard and starts forwarding its
+// EvKeys events to hid in a background goroutine. Call Close to ungrab
+// the device and stop forwarding.
+func PassthroughDevice(path string, hid *UsbHid) (*PassthroughReader, error) {
+	return openPassthrough(path, hid, nil)
+}
+
+// PassthroughMouseDevice opens path as a mouse and starts forwarding its
+// button and relative-motion events to mouse in a background goroutine,
+// so a mouse can be grabbed and passed through alongside a keyboard
+// opened with PassthroughDevice. Call Close to ungrab the device and
+// stop forwarding.
+func PassthroughMouseDevice(path string, mouse *UsbMouse) (*PassthroughReader, error) {
+	return openPassthrough(path, nil, mouse)
+}
+
+func openPassthrough(path string, hid *UsbHid, mouse *UsbMouse) (*PassthroughReader, error) {
+	device, err := evdev.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !device.Grab() {
+		device.Close()
+		return nil, fmt.Errorf("hidg: failed to grab %s", path)
+	}
+
+	pr := &PassthroughReader{
+		device: device,
+		hid:    hid,
+		mouse:  mouse,
+		exit:   make(chan struct{}),
+	}
+
+	go pr.run()
+
+	return pr, nil
+}
+
+func (pr *PassthroughReader) run() {
+	for {
+		select {
+		case <-pr.exit:
+			return
+		case ev, ok := <-pr.device.Inbox:
+			if !ok {
+				return
+			}
+
+			switch {
+			case pr.mouse != nil:
+				if ev.Type == evdev.EvKeys || ev.Type == evdev.EvRelative {
+					pr.mouse.ForwardRawEvent(ev)
+				}
+			case pr.hid != nil:
+				if ev.Type == evdev.EvKeys {
+					pr.hid.ForwardEvent(ev)
+				}
+			}
+		}
+	}
+}
+
+// Close ungrabs the underlying device and stops forwarding its events.
+func (pr *PassthroughReader) Close() {
+	close(pr.exit)
+	pr.device.Close()
+}
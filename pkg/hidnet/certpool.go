@@ -0,0 +1,20 @@
+package hidnet
+
+import (
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("hidnet: no certificates found in %s", caFile)
+	}
+	return pool, nil
+}
@@ -0,0 +1,167 @@
+// Package hidnet lets a remote client inject keyboard events into a local
+// HID keyboard gadget over the network, turning a Pi running hidg into a
+// network-attached keyboard. Every received event goes to the keyboard
+// gadget only; mouse buttons and relative motion are not forwarded to a
+// mouse gadget.
+//
+// The wire protocol is a fixed 8-byte frame per evdev event:
+//
+//	offset 0: uint16 big-endian Type
+//	offset 2: uint16 big-endian Code
+//	offset 4: int32  big-endian Value
+//
+// which is exactly the three fields of evdev.Event, so the server can
+// forward a frame straight into UsbHid.ForwardEvent without a parsing
+// step. A JSON-over-WebSocket transport carrying the same fields is
+// available in ws.go for clients that can't speak raw TCP.
+package hidnet
+
+import (
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/grimpy/hidg"
+	"github.com/maugsburger/evdev"
+	"golang.org/x/time/rate"
+)
+
+// dialTimeout bounds how long DialAndSend waits for the TLS handshake.
+const dialTimeout = 10 * time.Second
+
+const frameSize = 8
+
+// Server accepts event streams from remote clients and forwards them
+// into a local HID gadget.
+type Server struct {
+	hid      *hidg.UsbHid
+	tlsConf  *tls.Config
+	rateSpec rate.Limit
+	burst    int
+}
+
+// NewServer wraps hid so that events received over the network are
+// forwarded into it via hid.ForwardEvent. tlsConf should require and
+// verify a client certificate (mutual TLS); see LoadServerTLSConfig.
+// eventsPerSec/burst bound how many events per second a single
+// connection may inject, to blunt a malicious or runaway client.
+func NewServer(hid *hidg.UsbHid, tlsConf *tls.Config, eventsPerSec float64, burst int) *Server {
+	return &Server{
+		hid:      hid,
+		tlsConf:  tlsConf,
+		rateSpec: rate.Limit(eventsPerSec),
+		burst:    burst,
+	}
+}
+
+// LoadServerTLSConfig builds a tls.Config requiring and verifying client
+// certificates against caFile, for mutual-TLS authentication of clients.
+func LoadServerTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := loadCertPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
+
+// ListenAndServe binds addr and serves the binary frame protocol over
+// mutual TLS until the listener errors (e.g. is closed).
+func (s *Server) ListenAndServe(addr string) error {
+	ln, err := tls.Listen("tcp", addr, s.tlsConf)
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.handleConn(conn)
+	}
+}
+
+func (s *Server) handleConn(conn net.Conn) {
+	defer conn.Close()
+
+	limiter := rate.NewLimiter(s.rateSpec, s.burst)
+
+	var buf [frameSize]byte
+	for {
+		if _, err := io.ReadFull(conn, buf[:]); err != nil {
+			if err != io.EOF {
+				log.Printf("hidnet: read from %s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+
+		if !limiter.Allow() {
+			log.Printf("hidnet: dropping event from %s: rate limit exceeded", conn.RemoteAddr())
+			continue
+		}
+
+		ev := evdev.Event{
+			Type:  binary.BigEndian.Uint16(buf[0:2]),
+			Code:  binary.BigEndian.Uint16(buf[2:4]),
+			Value: int32(binary.BigEndian.Uint32(buf[4:8])),
+		}
+		s.hid.ForwardEvent(ev)
+	}
+}
+
+// DialAndSend is the client side of the binary protocol: it dials addr
+// over TLS and returns a function that sends a single event per call.
+// Callers are responsible for closing the returned io.Closer.
+func DialAndSend(addr string, tlsConf *tls.Config) (send func(evdev.Event) error, closer io.Closer, err error) {
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	conn, err := tls.DialWithDialer(dialer, "tcp", addr, tlsConf)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	send = func(ev evdev.Event) error {
+		var buf [frameSize]byte
+		binary.BigEndian.PutUint16(buf[0:2], ev.Type)
+		binary.BigEndian.PutUint16(buf[2:4], ev.Code)
+		binary.BigEndian.PutUint32(buf[4:8], uint32(ev.Value))
+		_, err := conn.Write(buf[:])
+		return err
+	}
+
+	return send, conn, nil
+}
+
+// LoadClientTLSConfig builds a tls.Config presenting a client certificate
+// and verifying the server against caFile, for mutual-TLS authentication.
+func LoadClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool, err := loadCertPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      pool,
+		MinVersion:   tls.VersionTLS12,
+	}, nil
+}
@@ -0,0 +1,76 @@
+package hidnet
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/websocket"
+	"github.com/maugsburger/evdev"
+	"golang.org/x/time/rate"
+)
+
+// wsFrame is the JSON wire shape for the WebSocket transport; it mirrors
+// the binary protocol's three evdev.Event fields.
+type wsFrame struct {
+	Type  uint16 `json:"type"`
+	Code  uint16 `json:"code"`
+	Value int32  `json:"value"`
+}
+
+var upgrader = websocket.Upgrader{
+	// The client is a dedicated hidg-remote binary, not a browser page,
+	// so there's no cross-origin concern here.
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// WebSocketHandler returns an http.Handler that upgrades to a WebSocket
+// connection and forwards each {type, code, value} JSON frame into hid,
+// rate-limited the same way the binary TCP transport is.
+func (s *Server) WebSocketHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			log.Printf("hidnet: websocket upgrade from %s: %v", r.RemoteAddr, err)
+			return
+		}
+		defer conn.Close()
+
+		limiter := rate.NewLimiter(s.rateSpec, s.burst)
+
+		for {
+			var frame wsFrame
+			if err := conn.ReadJSON(&frame); err != nil {
+				return
+			}
+
+			if !limiter.Allow() {
+				log.Printf("hidnet: dropping event from %s: rate limit exceeded", r.RemoteAddr)
+				continue
+			}
+
+			s.hid.ForwardEvent(evdev.Event{Type: frame.Type, Code: frame.Code, Value: frame.Value})
+		}
+	})
+}
+
+// DialWebSocket is the client side of the WebSocket transport: it
+// connects to url (ws:// or wss://) and returns a function that sends a
+// single event per call.
+func DialWebSocket(url string, dialer *websocket.Dialer) (send func(evdev.Event) error, closer *websocket.Conn, err error) {
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	send = func(ev evdev.Event) error {
+		frame := wsFrame{Type: ev.Type, Code: ev.Code, Value: ev.Value}
+		data, err := json.Marshal(frame)
+		if err != nil {
+			return err
+		}
+		return conn.WriteMessage(websocket.TextMessage, data)
+	}
+
+	return send, conn, nil
+}